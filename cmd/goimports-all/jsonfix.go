@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"io"
+	"strconv"
+)
+
+// importFix describes a single import operation that explains the
+// difference between a file's original and goimports-formatted import
+// blocks, in the same terms an editor applies ImportFix suggestions.
+type importFix struct {
+	Kind string `json:"kind"` // "add", "delete", or "rename"
+	Path string `json:"path"`
+	Name string `json:"name,omitempty"`
+	// Pos is "file:line:col" of the *ast.ImportSpec this fix came from: in
+	// res for "add"/"rename" (where the import now lives), in src for
+	// "delete" (where it used to live, since it's gone from res).
+	Pos string `json:"pos,omitempty"`
+}
+
+// fileFixes is the -json payload for a single processed file.
+type fileFixes struct {
+	File   string      `json:"file"`
+	Fixes  []importFix `json:"fixes"`
+	Result string      `json:"result"` // base64-encoded formatted file
+}
+
+// writeJSONFixes encodes the import fixes between src and res as a single
+// JSON object and writes it to out.
+func writeJSONFixes(out io.Writer, filename string, src, res []byte) error {
+	fixes, err := importFixes(filename, src, res)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(out).Encode(fileFixes{
+		File:   filename,
+		Fixes:  fixes,
+		Result: base64.StdEncoding.EncodeToString(res),
+	})
+}
+
+// importFixes diffs the import blocks of src and res (the latter being the
+// output of imports.Process) and reports the add/delete/rename operations
+// that explain the difference.
+//
+// The exported golang.org/x/tools/imports API only hands back finished
+// bytes, not the internal/imports.ImportFix values that produced them (that
+// type lives in an internal package and can't be imported from outside
+// golang.org/x/tools), so we recover the same information by comparing the
+// import sets before and after formatting instead.
+func importFixes(filename string, src, res []byte) ([]importFix, error) {
+	before, err := importSet(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	after, err := importSet(filename, res)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixes []importFix
+	for path, entry := range after {
+		oldEntry, existed := before[path]
+		switch {
+		case !existed:
+			fixes = append(fixes, importFix{Kind: "add", Path: path, Name: entry.Name, Pos: entry.Pos})
+		case oldEntry.Name != entry.Name:
+			fixes = append(fixes, importFix{Kind: "rename", Path: path, Name: entry.Name, Pos: entry.Pos})
+		}
+	}
+	for path, entry := range before {
+		if _, ok := after[path]; !ok {
+			fixes = append(fixes, importFix{Kind: "delete", Path: path, Name: entry.Name, Pos: entry.Pos})
+		}
+	}
+	return fixes, nil
+}
+
+// importEntry is one *ast.ImportSpec's local name and source position.
+type importEntry struct {
+	Name string
+	Pos  string
+}
+
+// importSet returns the import-path -> importEntry mapping for src, with
+// Name left blank for unaliased imports.
+func importSet(filename string, src []byte) (map[string]importEntry, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]importEntry, len(f.Imports))
+	for _, spec := range f.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		set[path] = importEntry{Name: name, Pos: fset.Position(spec.Pos()).String()}
+	}
+	return set, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func TestImportFixes(t *testing.T) {
+	const src = `package p
+
+import "fmt"
+
+func F() {
+	fmt.Println(bytes.NewBuffer(nil))
+}
+`
+	const res = `package p
+
+import (
+	"bytes"
+	"fmt"
+)
+
+func F() {
+	fmt.Println(bytes.NewBuffer(nil))
+}
+`
+
+	fixes, err := importFixes("p.go", []byte(src), []byte(res))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixes) != 1 {
+		t.Fatalf("got %d fixes, want 1: %+v", len(fixes), fixes)
+	}
+
+	f := fixes[0]
+	if f.Kind != "add" || f.Path != "bytes" {
+		t.Fatalf("unexpected fix: %+v", f)
+	}
+	if f.Pos == "" {
+		t.Fatalf("fix has no Pos: %+v", f)
+	}
+}
+
+func TestImportFixesDelete(t *testing.T) {
+	const src = `package p
+
+import (
+	"bytes"
+	"fmt"
+)
+
+func F() {
+	fmt.Println("hi")
+}
+`
+	const res = `package p
+
+import "fmt"
+
+func F() {
+	fmt.Println("hi")
+}
+`
+
+	fixes, err := importFixes("p.go", []byte(src), []byte(res))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixes) != 1 {
+		t.Fatalf("got %d fixes, want 1: %+v", len(fixes), fixes)
+	}
+
+	f := fixes[0]
+	if f.Kind != "delete" || f.Path != "bytes" {
+		t.Fatalf("unexpected fix: %+v", f)
+	}
+	if f.Pos == "" {
+		t.Fatalf("fix has no Pos: %+v", f)
+	}
+}
@@ -9,9 +9,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"golang.org/x/tools/imports"
+
+	"github.com/uRadical/goimports-all/pkg/format"
 )
 
 var (
@@ -23,29 +26,68 @@ var (
 	formatOnly = flag.Bool("format-only", false, "if true, don't fix imports and only format")
 	srcDir     = flag.String("srcdir", "", "choose imports as if source code is from `dir`")
 	verbose    = flag.Bool("v", false, "verbose logging")
+	numWorkers = flag.Int("p", runtime.NumCPU(), "number of files to process in parallel when walking a directory (parallelizes parsing/formatting per file; the module/GOPATH resolver itself is not cached across files, since that cache lives in golang.org/x/tools/internal/imports and isn't reachable from outside that module)")
+	jsonOut    = flag.Bool("json", false, "emit one JSON object per file describing the import fixes, instead of rewriting or diffing")
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run contains the body of main. Everything funnels through this single
+// function, returning an exit code instead of calling os.Exit directly, so
+// that the profiling cleanup deferred below (which flushes the CPU profile
+// and writes the heap profile) always runs: os.Exit skips deferred calls, so
+// calling it from deep inside the walk would silently drop those profiles.
+func run() int {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "usage: goimports-all [flags] [path ...]\n")
 		flag.PrintDefaults()
 	}
 	flag.Parse()
 
+	cleanup, err := startProfiling()
+	defer cleanup()
+	if err != nil {
+		report(err)
+		return exitCode
+	}
+
+	rw, err := parseRewriteRules(rewriteRules)
+	if err != nil {
+		report(err)
+		return exitCode
+	}
+	activeRewrites = rw
+
+	fm := format.NewFormatter(newOptions(), *localPkg)
+
 	if flag.NArg() == 0 {
-		if err := processFile("<standard input>", os.Stdin, os.Stdout); err != nil {
+		if err := processFile(fm, "<standard input>", os.Stdin, os.Stdout); err != nil {
 			report(err)
 		}
-		os.Exit(exitCode)
+		return exitCode
 	}
 
 	for i := range flag.NArg() {
 		path := flag.Arg(i)
-		if err := processPath(path); err != nil {
+		if err := processPath(fm, path); err != nil {
 			report(err)
 		}
 	}
-	os.Exit(exitCode)
+	return exitCode
+}
+
+// newOptions builds the imports.Options shared by every file in this run.
+func newOptions() imports.Options {
+	return imports.Options{
+		TabWidth:   8,
+		TabIndent:  true,
+		Comments:   true,
+		Fragment:   true,
+		FormatOnly: *formatOnly,
+		AllErrors:  *allErrors,
+	}
 }
 
 var exitCode = 0
@@ -55,32 +97,14 @@ func report(err error) {
 	exitCode = 2
 }
 
-func processPath(path string) error {
+func processPath(fm *format.Formatter, path string) error {
 	// Handle ./... pattern
 	if strings.HasSuffix(path, "/...") || path == "..." {
 		dir := strings.TrimSuffix(path, "/...")
 		if dir == "" || dir == "." {
 			dir = "."
 		}
-		return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if info.IsDir() {
-				// Skip vendor and hidden directories
-				if info.Name() == "vendor" || strings.HasPrefix(info.Name(), ".") {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-			if !isGoFile(info) {
-				return nil
-			}
-			if err := processFile(path, nil, os.Stdout); err != nil {
-				report(err)
-			}
-			return nil
-		})
+		return processDir(fm, dir, true)
 	}
 
 	info, err := os.Stat(path)
@@ -89,32 +113,32 @@ func processPath(path string) error {
 	}
 
 	if info.IsDir() {
-		return filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if info.IsDir() {
-				return nil
-			}
-			if !isGoFile(info) {
-				return nil
-			}
-			if err := processFile(path, nil, os.Stdout); err != nil {
-				report(err)
-			}
-			return nil
-		})
+		return processDir(fm, path, false)
 	}
 
-	return processFile(path, nil, os.Stdout)
+	return processFile(fm, path, nil, os.Stdout)
 }
 
-func isGoFile(f os.FileInfo) bool {
-	name := f.Name()
-	return !f.IsDir() && !strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".go")
+// processDir formats every Go file beneath dir using format.Formatter's
+// bounded worker pool, writing each file's output to stdout in walk order.
+func processDir(fm *format.Formatter, dir string, skipVendorAndHidden bool) error {
+	return fm.FormatTree(dir, format.WalkOptions{
+		Workers:             *numWorkers,
+		SkipVendorAndHidden: skipVendorAndHidden,
+		Preprocess: func(path string, src []byte) ([]byte, error) {
+			return applyRewrites(path, src, activeRewrites)
+		},
+		OnResult: func(path string, src, res []byte, changed bool, err error) error {
+			if err != nil {
+				report(err)
+				return nil
+			}
+			return emit(os.Stdout, path, src, res, changed)
+		},
+	})
 }
 
-func processFile(filename string, in io.Reader, out io.Writer) error {
+func processFile(fm *format.Formatter, filename string, in io.Reader, out io.Writer) error {
 	if *verbose {
 		fmt.Fprintf(os.Stderr, "processing %s\n", filename)
 	}
@@ -131,17 +155,10 @@ func processFile(filename string, in io.Reader, out io.Writer) error {
 		return err
 	}
 
-	opt := &imports.Options{
-		TabWidth:   8,
-		TabIndent:  true,
-		Comments:   true,
-		Fragment:   true,
-		FormatOnly: *formatOnly,
-		AllErrors:  *allErrors,
-	}
-
-	if *localPkg != "" {
-		imports.LocalPrefix = *localPkg
+	orig := src
+	src, err = applyRewrites(filename, src, activeRewrites)
+	if err != nil {
+		return err
 	}
 
 	target := filename
@@ -149,12 +166,26 @@ func processFile(filename string, in io.Reader, out io.Writer) error {
 		target = filepath.Join(*srcDir, filepath.Base(filename))
 	}
 
-	res, err := imports.Process(target, src, opt)
+	res, _, err := fm.FormatFile(target, src)
 	if err != nil {
 		return err
 	}
 
-	if !bytes.Equal(src, res) {
+	// changed/diff are measured against the true on-disk original, not the
+	// rewritten-but-not-yet-goimports'd buffer: otherwise a -r rewrite whose
+	// output happens to already match res apart from the rewritten import
+	// text is reported (and written) as unchanged.
+	return emit(out, filename, orig, res, !bytes.Equal(orig, res))
+}
+
+// emit applies the -json/-l/-w/-d output modes to a single file's formatting
+// result.
+func emit(out io.Writer, filename string, src, res []byte, changed bool) error {
+	if *jsonOut {
+		return writeJSONFixes(out, filename, src, res)
+	}
+
+	if changed {
 		if *list {
 			if _, err := fmt.Fprintln(out, filename); err != nil {
 				return err
@@ -177,10 +208,11 @@ func processFile(filename string, in io.Reader, out io.Writer) error {
 	}
 
 	if !*list && !*write && !*doDiff {
-		_, err = out.Write(res)
+		_, err := out.Write(res)
+		return err
 	}
 
-	return err
+	return nil
 }
 
 func diff(b1, b2 []byte, filename string) ([]byte, error) {
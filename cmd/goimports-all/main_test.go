@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uRadical/goimports-all/pkg/format"
+)
+
+// Regression test for a bug where -r rewrites were silently dropped by -w:
+// changed/diff was computed against the post-rewrite buffer instead of the
+// true on-disk original, so a rewrite whose output happened to already match
+// the final formatted bytes (modulo the rewritten import text) never landed.
+func TestProcessFileRewriteWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.go")
+	const src = `package a
+
+import old "example.com/old/path"
+
+func F() { old.F() }
+`
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	*write = true
+	defer func() { *write = false }()
+
+	rw, err := parseRewriteRules([]string{"example.com/old/path=example.com/new/path"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeRewrites = rw
+	defer func() { activeRewrites = nil }()
+
+	fm := format.NewFormatter(newOptions(), "")
+	if err := processFile(fm, path, nil, new(bytes.Buffer)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(got), "example.com/old/path") {
+		t.Fatalf("rewrite did not land on disk, old import path still present:\n%s", got)
+	}
+	if !strings.Contains(string(got), "example.com/new/path") {
+		t.Fatalf("rewritten import path missing from disk:\n%s", got)
+	}
+}
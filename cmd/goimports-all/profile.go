@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+var (
+	cpuProfile = flag.String("cpuprofile", "", "write a CPU profile to `file`")
+	memProfile = flag.String("memprofile", "", "write a heap profile to `file`")
+	traceFile  = flag.String("trace", "", "write an execution trace to `file`")
+)
+
+// startProfiling begins CPU profiling and/or tracing if requested by flags.
+// It returns a cleanup function that stops them and writes the heap profile;
+// the caller must run it (via defer, before exiting) regardless of the error
+// return, so that anything already started gets flushed.
+func startProfiling() (cleanup func(), err error) {
+	var closers []func()
+	cleanup = func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return cleanup, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return cleanup, err
+		}
+		closers = append(closers, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			return cleanup, err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return cleanup, err
+		}
+		closers = append(closers, func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+
+	if *memProfile != "" {
+		closers = append(closers, func() {
+			f, err := os.Create(*memProfile)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return
+			}
+			defer f.Close()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		})
+	}
+
+	return cleanup, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStartProfilingWritesFiles(t *testing.T) {
+	dir := t.TempDir()
+	*cpuProfile = filepath.Join(dir, "cpu.pprof")
+	*memProfile = filepath.Join(dir, "mem.pprof")
+	*traceFile = filepath.Join(dir, "trace.out")
+	defer func() {
+		*cpuProfile = ""
+		*memProfile = ""
+		*traceFile = ""
+	}()
+
+	cleanup, err := startProfiling()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Do a little work so the CPU profile has something to sample.
+	sum := 0
+	for i := 0; i < 1e6; i++ {
+		sum += i
+	}
+	_ = sum
+
+	cleanup()
+
+	for _, path := range []string{*cpuProfile, *memProfile, *traceFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", path, err)
+		}
+		if info.Size() == 0 {
+			t.Fatalf("expected %s to be non-empty", path)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyRewritesIdentSkipsShadowedDecl(t *testing.T) {
+	const src = `package p
+
+import old "example.com/old/path"
+
+type T struct{}
+
+func (old T) Bar() {}
+
+func Use() {
+	var old T
+	old.Bar()
+}
+
+func UseImport() {
+	old.Baz()
+}
+`
+
+	rw, err := parseRewriteRules([]string{"old.Baz->old.Qux"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := applyRewrites("p.go", []byte(src), rw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+
+	if !strings.Contains(got, "old.Qux()") {
+		t.Fatalf("import-qualified call was not rewritten:\n%s", got)
+	}
+	if !strings.Contains(got, "old.Bar()") {
+		t.Fatalf("local-variable/method call was rewritten when it shouldn't have been:\n%s", got)
+	}
+}
+
+func TestApplyRewritesImportPath(t *testing.T) {
+	const src = `package p
+
+import old "example.com/old/path"
+
+func Use() { old.F() }
+`
+
+	rw, err := parseRewriteRules([]string{"example.com/old/path=example.com/new/path"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := applyRewrites("p.go", []byte(src), rw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+
+	if strings.Contains(got, "example.com/old/path") {
+		t.Fatalf("old import path still present:\n%s", got)
+	}
+	if !strings.Contains(got, "example.com/new/path") {
+		t.Fatalf("new import path missing:\n%s", got)
+	}
+}
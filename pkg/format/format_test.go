@@ -0,0 +1,120 @@
+package format
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/tools/imports"
+)
+
+// TestFormatFileAddsImport exercises FormatFile as a library call, with no
+// CLI involved: a missing import should be added, and the before/after
+// change should be reported correctly.
+func TestFormatFileAddsImport(t *testing.T) {
+	fm := NewFormatter(imports.Options{Comments: true, TabWidth: 8}, "")
+
+	const src = `package p
+
+func F() {
+	fmt.Println("hi")
+}
+`
+	result, changed, err := fm.FormatFile("p.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("expected FormatFile to report a change")
+	}
+	if !strings.Contains(string(result), `"fmt"`) {
+		t.Fatalf("expected fmt import to be added, got:\n%s", result)
+	}
+}
+
+// TestFormatTreeConcurrent writes enough files that FormatTree's worker pool
+// must actually run more than one at a time, then checks every file got
+// formatted and OnResult still saw them in walk order despite that
+// concurrency.
+func TestFormatTreeConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	const n = 20
+	var names []string
+	for i := 0; i < n; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("f%02d.go", i))
+		src := "package p\n\nfunc F() {\n\tfmt.Println(\"hi\")\n}\n"
+		if err := os.WriteFile(name, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fm := NewFormatter(imports.Options{Comments: true, TabWidth: 8}, "")
+
+	var mu sync.Mutex
+	var seen []string
+	err := fm.FormatTree(dir, WalkOptions{
+		Workers: 4,
+		OnResult: func(path string, src, result []byte, changed bool, err error) error {
+			if err != nil {
+				return err
+			}
+			if !changed {
+				t.Errorf("expected %s to be changed", path)
+			}
+			mu.Lock()
+			seen = append(seen, path)
+			mu.Unlock()
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(seen) != n {
+		t.Fatalf("got %d results, want %d", len(seen), n)
+	}
+	if !sort.StringsAreSorted(seen) {
+		t.Fatalf("OnResult was not called in walk order: %v", seen)
+	}
+}
+
+// TestFormatTreeSkipsVendorAndHidden checks WalkOptions.SkipVendorAndHidden
+// actually prunes those directories rather than just skipping the files in
+// them after descending.
+func TestFormatTreeSkipsVendorAndHidden(t *testing.T) {
+	dir := t.TempDir()
+	for _, rel := range []string{"a.go", "vendor/b.go", ".git/c.go"} {
+		full := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("package p\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fm := NewFormatter(imports.Options{Comments: true, TabWidth: 8}, "")
+
+	var seen []string
+	err := fm.FormatTree(dir, WalkOptions{
+		SkipVendorAndHidden: true,
+		OnResult: func(path string, src, result []byte, changed bool, err error) error {
+			seen = append(seen, path)
+			return err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(seen) != 1 || filepath.Base(seen[0]) != "a.go" {
+		t.Fatalf("expected only a.go to be visited, got %v", seen)
+	}
+}